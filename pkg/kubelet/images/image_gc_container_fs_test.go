@@ -0,0 +1,147 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	statsapi "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+	"k8s.io/kubernetes/pkg/kubelet/container"
+	statstest "k8s.io/kubernetes/pkg/kubelet/server/stats/testing"
+)
+
+func TestNewImageGCManagerDefaultsContainerFsThresholds(t *testing.T) {
+	policy := ImageGCPolicy{HighThresholdPercent: 90, LowThresholdPercent: 80}
+	manager, err := NewImageGCManager(nil, nil, nil, nil, policy, oteltrace.NewNoopTracerProvider())
+	require.NoError(t, err)
+
+	im := manager.(*realImageGCManager)
+	assert.Equal(t, 90, im.policy.ContainerFsHighThresholdPercent)
+	assert.Equal(t, 80, im.policy.ContainerFsLowThresholdPercent)
+}
+
+func TestNewImageGCManagerAcceptsExplicitContainerFsThresholds(t *testing.T) {
+	policy := ImageGCPolicy{
+		HighThresholdPercent:            90,
+		LowThresholdPercent:             80,
+		ContainerFsHighThresholdPercent: 95,
+		ContainerFsLowThresholdPercent:  85,
+	}
+	manager, err := NewImageGCManager(nil, nil, nil, nil, policy, oteltrace.NewNoopTracerProvider())
+	require.NoError(t, err)
+
+	im := manager.(*realImageGCManager)
+	assert.Equal(t, 95, im.policy.ContainerFsHighThresholdPercent)
+	assert.Equal(t, 85, im.policy.ContainerFsLowThresholdPercent)
+}
+
+func TestNewImageGCManagerRejectsInvalidContainerFsThresholds(t *testing.T) {
+	_, err := NewImageGCManager(nil, nil, nil, nil, ImageGCPolicy{
+		HighThresholdPercent:            90,
+		LowThresholdPercent:             80,
+		ContainerFsHighThresholdPercent: 70,
+		ContainerFsLowThresholdPercent:  85,
+	}, nil)
+	assert.EqualError(t, err, "ContainerFsLowThresholdPercent 85 can not be higher than ContainerFsHighThresholdPercent 70")
+}
+
+func TestGarbageCollectTriggersOnContainerFsHighThreshold(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	// Image filesystem is comfortably under its own thresholds; only the
+	// container filesystem is over its high threshold.
+	policy := ImageGCPolicy{
+		HighThresholdPercent:            90,
+		LowThresholdPercent:             80,
+		ContainerFsHighThresholdPercent: 90,
+		ContainerFsLowThresholdPercent:  80,
+	}
+	manager, fakeRuntime := newRealImageGCManager(policy, mockStatsProvider)
+	fakeRuntime.ImageList = []container.Image{makeImage(0, 500)}
+
+	mockStatsProvider.EXPECT().ImageFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(900),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+	mockStatsProvider.EXPECT().ContainerFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(50),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+
+	require.NoError(t, manager.GarbageCollect(ctx))
+	assert.Len(t, fakeRuntime.ImageList, 0, "container filesystem pressure alone should have triggered image GC")
+}
+
+func TestGarbageCollectSkipsWhenBothFilesystemsBelowThresholds(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	policy := ImageGCPolicy{
+		HighThresholdPercent:            90,
+		LowThresholdPercent:             80,
+		ContainerFsHighThresholdPercent: 90,
+		ContainerFsLowThresholdPercent:  80,
+	}
+	manager, fakeRuntime := newRealImageGCManager(policy, mockStatsProvider)
+	fakeRuntime.ImageList = []container.Image{makeImage(0, 500)}
+
+	mockStatsProvider.EXPECT().ImageFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(900),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+	mockStatsProvider.EXPECT().ContainerFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(900),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+
+	require.NoError(t, manager.GarbageCollect(ctx))
+	assert.Len(t, fakeRuntime.ImageList, 1, "neither filesystem is over its high threshold, nothing should be removed")
+}
+
+func TestGarbageCollectNotConfiguredSkipsContainerFsStats(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	// ContainerFs thresholds are left at their zero value, as they would be
+	// for a manager built directly (bypassing NewImageGCManager's
+	// defaulting) or for code predating this feature; ContainerFsStats must
+	// not be called in that case.
+	policy := ImageGCPolicy{HighThresholdPercent: 90, LowThresholdPercent: 80}
+	manager, fakeRuntime := newRealImageGCManager(policy, mockStatsProvider)
+	fakeRuntime.ImageList = []container.Image{makeImage(0, 500)}
+
+	mockStatsProvider.EXPECT().ImageFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(900),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+
+	require.NoError(t, manager.GarbageCollect(ctx))
+	assert.Len(t, fakeRuntime.ImageList, 1)
+}
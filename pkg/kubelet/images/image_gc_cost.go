@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/kubernetes/pkg/kubelet/container"
+)
+
+// costWeightedEvictionPolicy ranks eviction candidates by
+// age*size/pullCostEstimate, descending, so the highest-scoring (cheapest to
+// lose) image is evicted first. This lets operators keep expensive-to-repull
+// images, such as large ML models served from a slow registry, on-node
+// longer than a strict LRU policy would.
+type costWeightedEvictionPolicy struct {
+	// registryLatencyHints scales the pull cost estimate of images pulled
+	// from the given registry host. Nil is equivalent to an empty map.
+	registryLatencyHints map[string]time.Duration
+}
+
+func (p costWeightedEvictionPolicy) Rank(candidates []evictionInfo, now time.Time) []evictionInfo {
+	ranked := append([]evictionInfo(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return p.score(ranked[i], now) > p.score(ranked[j], now)
+	})
+	return ranked
+}
+
+func (p costWeightedEvictionPolicy) score(candidate evictionInfo, now time.Time) float64 {
+	age := now.Sub(candidate.lastUsed).Seconds()
+	if age < 0 {
+		age = 0
+	}
+	return age * float64(candidate.size) / p.pullCostEstimate(candidate)
+}
+
+// pullCostEstimate approximates how expensive re-pulling candidate would be:
+// its on-disk size, multiplied by the RegistryLatencyHint (in seconds)
+// configured for the registry it was pulled from, if any. A longer hinted
+// latency makes the image more
+// expensive to lose, so it scores as a worse eviction candidate.
+func (p costWeightedEvictionPolicy) pullCostEstimate(candidate evictionInfo) float64 {
+	cost := float64(candidate.size)
+	if cost <= 0 {
+		cost = 1
+	}
+	if latency, ok := p.registryLatencyHints[candidate.registryHost]; ok && latency > 0 {
+		cost *= latency.Seconds()
+	}
+	return cost
+}
+
+// primaryRegistryHost returns the registry host of the first reference the
+// runtime reports for image (preferring repo tags over repo digests), or
+// the empty string if it reported none.
+func primaryRegistryHost(image container.Image) string {
+	if len(image.RepoTags) > 0 {
+		return registryHost(image.RepoTags[0])
+	}
+	if len(image.RepoDigests) > 0 {
+		return registryHost(image.RepoDigests[0])
+	}
+	return ""
+}
+
+// registryHost returns the registry host portion of ref, defaulting to
+// "docker.io" for unqualified references, matching common container image
+// reference conventions (e.g. "nginx:latest" and "library/nginx:latest"
+// both resolve to docker.io).
+func registryHost(ref string) string {
+	slash := strings.Index(ref, "/")
+	if slash == -1 {
+		return "docker.io"
+	}
+	host := ref[:slash]
+	if host != "localhost" && !strings.ContainsAny(host, ".:") {
+		return "docker.io"
+	}
+	return host
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/kubernetes/pkg/kubelet/container"
+)
+
+func TestRegistryHost(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{ref: "nginx:latest", want: "docker.io"},
+		{ref: "library/nginx:latest", want: "docker.io"},
+		{ref: "registry.k8s.io/pause:3.9", want: "registry.k8s.io"},
+		{ref: "localhost:5000/my-image:v1", want: "localhost:5000"},
+		{ref: "quay.io/org/image@sha256:deadbeef", want: "quay.io"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ref, func(t *testing.T) {
+			assert.Equal(t, test.want, registryHost(test.ref))
+		})
+	}
+}
+
+func TestPrimaryRegistryHost(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("registry.k8s.io", primaryRegistryHost(container.Image{
+		RepoTags: []string{"registry.k8s.io/pause:3.9"},
+	}))
+	assert.Equal("quay.io", primaryRegistryHost(container.Image{
+		RepoDigests: []string{"quay.io/org/image@sha256:deadbeef"},
+	}))
+	assert.Equal("", primaryRegistryHost(container.Image{}))
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// EvictionPolicy ranks unused image candidates for garbage collection, from
+// the best eviction candidate (index 0) to the worst.
+type EvictionPolicy interface {
+	Rank(candidates []evictionInfo, now time.Time) []evictionInfo
+}
+
+// evictionPolicyForStrategy returns the EvictionPolicy named by strategy, or
+// an error if strategy does not name one of the supported policies. The
+// empty string selects the default, "lru". registryLatencyHints is only
+// consulted by the "cost-weighted" strategy.
+func evictionPolicyForStrategy(strategy string, registryLatencyHints map[string]time.Duration) (EvictionPolicy, error) {
+	switch strategy {
+	case "", "lru":
+		return lruEvictionPolicy{}, nil
+	case "lfu":
+		return lfuEvictionPolicy{}, nil
+	case "size-weighted":
+		return sizeWeightedEvictionPolicy{}, nil
+	case "gdsf":
+		return gdsfEvictionPolicy{}, nil
+	case "cost-weighted":
+		return costWeightedEvictionPolicy{registryLatencyHints: registryLatencyHints}, nil
+	default:
+		return nil, fmt.Errorf("invalid EvictionStrategy %q, must be one of \"lru\", \"lfu\", \"size-weighted\", \"gdsf\", \"cost-weighted\"", strategy)
+	}
+}
+
+// lruEvictionPolicy evicts the least recently used image first, breaking
+// ties with the image that was first detected earliest. This is the
+// classic, pre-existing behavior.
+type lruEvictionPolicy struct{}
+
+func (lruEvictionPolicy) Rank(candidates []evictionInfo, now time.Time) []evictionInfo {
+	ranked := append([]evictionInfo(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].lastUsed.Equal(ranked[j].lastUsed) {
+			return ranked[i].firstDetected.Before(ranked[j].firstDetected)
+		}
+		return ranked[i].lastUsed.Before(ranked[j].lastUsed)
+	})
+	return ranked
+}
+
+// lfuEvictionPolicy evicts the least frequently used image first (by
+// useCount), breaking ties by least recently used.
+type lfuEvictionPolicy struct{}
+
+func (lfuEvictionPolicy) Rank(candidates []evictionInfo, now time.Time) []evictionInfo {
+	ranked := append([]evictionInfo(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].useCount == ranked[j].useCount {
+			return ranked[i].lastUsed.Before(ranked[j].lastUsed)
+		}
+		return ranked[i].useCount < ranked[j].useCount
+	})
+	return ranked
+}
+
+// sizeWeightedEvictionPolicy evicts the largest images first, breaking ties
+// by least recently used. This favors reclaiming the most disk space per
+// eviction over strict recency.
+type sizeWeightedEvictionPolicy struct{}
+
+func (sizeWeightedEvictionPolicy) Rank(candidates []evictionInfo, now time.Time) []evictionInfo {
+	ranked := append([]evictionInfo(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].size == ranked[j].size {
+			return ranked[i].lastUsed.Before(ranked[j].lastUsed)
+		}
+		return ranked[i].size > ranked[j].size
+	})
+	return ranked
+}
+
+// gdsfEvictionPolicy implements GreedyDual-Size-Frequency: each candidate's
+// score is useCount*costPerByte/size + agingClock, and the lowest score is
+// evicted first. costPerByte is fixed at gdsfDefaultCostPerByte; for a
+// registry-latency-aware cost estimate, see costWeightedEvictionPolicy.
+// agingClock anchors newly observed images to the current aging baseline so
+// they aren't unfairly favored over images that have survived many eviction
+// rounds.
+type gdsfEvictionPolicy struct{}
+
+const gdsfDefaultCostPerByte = 1.0
+
+func (gdsfEvictionPolicy) score(candidate evictionInfo) float64 {
+	if candidate.size == 0 {
+		return candidate.agingClock
+	}
+	return float64(candidate.useCount)*gdsfDefaultCostPerByte/float64(candidate.size) + candidate.agingClock
+}
+
+func (p gdsfEvictionPolicy) Rank(candidates []evictionInfo, now time.Time) []evictionInfo {
+	ranked := append([]evictionInfo(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return p.score(ranked[i]) < p.score(ranked[j])
+	})
+	return ranked
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictionPolicyRank(t *testing.T) {
+	now := time.Now()
+	records := func() []evictionInfo {
+		return []evictionInfo{
+			{id: "old-rarely-used-small", imageRecord: imageRecord{
+				firstDetected: now.Add(-time.Hour), lastUsed: now.Add(-time.Hour), size: 100, useCount: 1,
+			}},
+			{id: "new-frequent-large", imageRecord: imageRecord{
+				firstDetected: now.Add(-time.Minute), lastUsed: now.Add(-time.Minute), size: 10000, useCount: 50,
+			}},
+			{id: "mid-moderate-medium", imageRecord: imageRecord{
+				firstDetected: now.Add(-30 * time.Minute), lastUsed: now.Add(-10 * time.Minute), size: 2000, useCount: 5,
+			}},
+		}
+	}
+
+	testCases := []struct {
+		strategy string
+		hints    map[string]time.Duration
+		want     []string
+	}{
+		{strategy: "lru", want: []string{"old-rarely-used-small", "mid-moderate-medium", "new-frequent-large"}},
+		{strategy: "lfu", want: []string{"old-rarely-used-small", "mid-moderate-medium", "new-frequent-large"}},
+		{strategy: "size-weighted", want: []string{"new-frequent-large", "mid-moderate-medium", "old-rarely-used-small"}},
+		{strategy: "gdsf", want: []string{"mid-moderate-medium", "new-frequent-large", "old-rarely-used-small"}},
+		{strategy: "cost-weighted", want: []string{"old-rarely-used-small", "mid-moderate-medium", "new-frequent-large"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			policy, err := evictionPolicyForStrategy(tc.strategy, tc.hints)
+			assert.NoError(t, err)
+
+			ranked := policy.Rank(records(), now)
+			got := make([]string, len(ranked))
+			for i, r := range ranked {
+				got[i] = r.id
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCostWeightedEvictionPolicyHonorsRegistryLatencyHints(t *testing.T) {
+	now := time.Now()
+	// Same size and age; only the registry differs, so the hint alone must
+	// decide the order.
+	slow := evictionInfo{id: "slow-registry", imageRecord: imageRecord{
+		lastUsed: now.Add(-time.Hour), size: 1000, registryHost: "slow.example.com",
+	}}
+	fast := evictionInfo{id: "fast-registry", imageRecord: imageRecord{
+		lastUsed: now.Add(-time.Hour), size: 1000, registryHost: "fast.example.com",
+	}}
+
+	policy, err := evictionPolicyForStrategy("cost-weighted", map[string]time.Duration{
+		"slow.example.com": time.Minute,
+		"fast.example.com": time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	ranked := policy.Rank([]evictionInfo{slow, fast}, now)
+	assert.Equal(t, []string{"fast-registry", "slow-registry"}, []string{ranked[0].id, ranked[1].id},
+		"the image pulled from the registry with the lower latency hint should be the cheaper, preferred eviction candidate")
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	statsapi "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+	evictionapi "k8s.io/kubernetes/pkg/kubelet/eviction/api"
+	statstest "k8s.io/kubernetes/pkg/kubelet/server/stats/testing"
+)
+
+func TestCheckHighThresholdAgainstHardEviction(t *testing.T) {
+	tests := []struct {
+		name                 string
+		highThresholdPercent int
+		threshold            evictionapi.Threshold
+		wantErr              bool
+	}{
+		{
+			name:                 "high threshold stricter than hard eviction threshold",
+			highThresholdPercent: 80,
+			threshold: evictionapi.Threshold{
+				Signal: evictionapi.SignalImageFsAvailable,
+				Value:  evictionapi.ThresholdValue{Percentage: 0.10}, // triggers at 90% used
+			},
+		},
+		{
+			name:                 "high threshold looser than hard eviction threshold",
+			highThresholdPercent: 95,
+			threshold: evictionapi.Threshold{
+				Signal: evictionapi.SignalImageFsAvailable,
+				Value:  evictionapi.ThresholdValue{Percentage: 0.10}, // triggers at 90% used
+			},
+			wantErr: true,
+		},
+		{
+			name:                 "soft eviction threshold (has grace period) is not compared",
+			highThresholdPercent: 95,
+			threshold: evictionapi.Threshold{
+				Signal:      evictionapi.SignalImageFsAvailable,
+				Value:       evictionapi.ThresholdValue{Percentage: 0.10},
+				GracePeriod: time.Minute,
+			},
+		},
+		{
+			name:                 "unrelated signal is not compared",
+			highThresholdPercent: 95,
+			threshold: evictionapi.Threshold{
+				Signal: evictionapi.SignalMemoryAvailable,
+				Value:  evictionapi.ThresholdValue{Percentage: 0.10},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkHighThresholdAgainstHardEviction(test.highThresholdPercent, test.threshold)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSetEvictionSignalsTriggersGarbageCollectOnHardThreshold(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	policy := ImageGCPolicy{HighThresholdPercent: 90, LowThresholdPercent: 80}
+	manager, _ := newRealImageGCManager(policy, mockStatsProvider)
+
+	collected := make(chan struct{}, 1)
+	mockStatsProvider.EXPECT().ImageFsStats(gomock.Any()).DoAndReturn(func(context.Context) (*statsapi.FsStats, error) {
+		collected <- struct{}{}
+		return &statsapi.FsStats{AvailableBytes: uint64Ptr(950), CapacityBytes: uint64Ptr(1000)}, nil
+	}).AnyTimes()
+
+	signals := make(chan evictionapi.Threshold)
+	manager.SetEvictionSignals(signals)
+
+	signals <- evictionapi.Threshold{Signal: evictionapi.SignalImageFsAvailable, Value: evictionapi.ThresholdValue{Percentage: 0.10}}
+
+	select {
+	case <-collected:
+	case <-time.After(time.Second):
+		t.Fatal("expected a hard threshold crossing to trigger an immediate GarbageCollect pass")
+	}
+}
+
+func TestGarbageCollectSerializesConcurrentRuns(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	policy := ImageGCPolicy{HighThresholdPercent: 90, LowThresholdPercent: 80}
+	manager, _ := newRealImageGCManager(policy, mockStatsProvider)
+
+	var active int32
+	var maxActive int32
+	mockStatsProvider.EXPECT().ImageFsStats(gomock.Any()).DoAndReturn(func(context.Context) (*statsapi.FsStats, error) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			old := atomic.LoadInt32(&maxActive)
+			if n <= old || atomic.CompareAndSwapInt32(&maxActive, old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		return &statsapi.FsStats{AvailableBytes: uint64Ptr(50), CapacityBytes: uint64Ptr(1000)}, nil
+	}).Times(2)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer wg.Done()
+			_ = manager.GarbageCollect(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	require.LessOrEqual(t, atomic.LoadInt32(&maxActive), int32(1), "concurrent GarbageCollect calls must be serialized by gcLock")
+}
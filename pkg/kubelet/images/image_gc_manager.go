@@ -0,0 +1,1084 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	statsapi "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+	"k8s.io/kubernetes/pkg/kubelet/container"
+	"k8s.io/kubernetes/pkg/kubelet/events"
+	evictionapi "k8s.io/kubernetes/pkg/kubelet/eviction/api"
+	"k8s.io/kubernetes/pkg/kubelet/server/stats"
+	"k8s.io/utils/clock"
+
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// ImageGCManager is an interface for managing lifecycle of all images.
+//
+// Implementation is thread-safe.
+type ImageGCManager interface {
+	// Applies the garbage collection policy. Errors include disk usage errors and
+	// errors connecting to the runtime.
+	GarbageCollect(ctx context.Context) error
+
+	// Start async garbage collection of images.
+	Start()
+
+	GetImageList() ([]container.Image, error)
+
+	// Delete all unused images.
+	DeleteUnusedImages(ctx context.Context) error
+
+	// PlanGarbageCollect returns the ordered list of images that a
+	// GarbageCollect call would remove right now, without removing them.
+	PlanGarbageCollect(ctx context.Context) (GCPlan, error)
+}
+
+// ImageGCPolicy is a policy for garbage collecting images. Policy defines an allowed band in
+// which garbage collection will be run.
+type ImageGCPolicy struct {
+	// Any usage above this threshold will always trigger garbage collection.
+	// This is the highest usage to which the garbage collector will attempt
+	// to free.
+	HighThresholdPercent int
+
+	// Any usage below this threshold will never trigger garbage collection.
+	// This is the lowest threshold the garbage collector will try to reach.
+	LowThresholdPercent int
+
+	// SoftThresholdPercent, like HardEvictionThresholds in pkg/kubelet/eviction,
+	// starts a grace-period timer once usage reaches it; garbage collection
+	// only runs if usage remains at or above it for SoftThresholdGracePeriod,
+	// so a transient spike from a single large pull doesn't trigger reclaim.
+	// HighThresholdPercent still triggers garbage collection immediately,
+	// regardless of the soft threshold. Zero disables the soft threshold.
+	SoftThresholdPercent int
+
+	// SoftThresholdGracePeriod is how long usage must remain at or above
+	// SoftThresholdPercent before it triggers garbage collection. Ignored if
+	// SoftThresholdPercent is zero. Must not be negative.
+	SoftThresholdGracePeriod time.Duration
+
+	// Minimum age at which an image can be garbage collected.
+	MinAge time.Duration
+
+	// MaxAge is the oldest allowed age for an unused image. If set to a non-zero
+	// value, images which have been unused for longer than MaxAge will be garbage
+	// collected regardless of disk usage.
+	MaxAge time.Duration
+
+	// NamespaceQuotas caps the disk share available to images whose most
+	// recent users matched a namespace selector or fell below a priority
+	// threshold. Images belonging to a bucket that is over its MaxPercent are
+	// preferred eviction candidates, ahead of the EvictionStrategy order. No
+	// disk share is reserved for any bucket.
+	NamespaceQuotas []NamespaceImageQuota
+
+	// EvictionStrategy selects the EvictionPolicy used to rank unused images
+	// for removal. One of "" (defaults to "lru"), "lru", "lfu",
+	// "size-weighted", or "gdsf".
+	EvictionStrategy string
+
+	// ProtectionWindow is the duration during which a freshly pulled image is
+	// skipped by freeSpace and freeOldImages, so an image that was just
+	// pulled for a pending pod isn't immediately reclaimed under disk
+	// pressure. If honoring the window would leave the node unable to reach
+	// LowThresholdPercent, freeSpace falls back to evicting protected images
+	// anyway and records a "ProtectedImageEvicted" event for each.
+	ProtectionWindow time.Duration
+
+	// DryRun, when true, makes GarbageCollect compute and log the removal
+	// plan it would otherwise execute, without actually removing any
+	// images. Use PlanGarbageCollect to retrieve the plan programmatically.
+	DryRun bool
+
+	// PinnedImageRefs is a list of image references, optionally including a
+	// digest, that must never be garbage collected, regardless of disk
+	// pressure. Matched against every reference the runtime reports for an
+	// image (its ID, repo tags, and repo digests).
+	PinnedImageRefs []string
+
+	// PinnedImagePatterns is a list of shell glob patterns (as accepted by
+	// path.Match) matched against every reference the runtime reports for
+	// an image. An image with at least one matching reference is pinned.
+	PinnedImagePatterns []string
+
+	// RegistryLatencyHints scales the pull cost estimate the "cost-weighted"
+	// EvictionStrategy uses, keyed by registry host (e.g. "registry.k8s.io",
+	// "docker.io"). A longer hinted latency makes images pulled from that
+	// registry more expensive to evict. Ignored by all other strategies.
+	RegistryLatencyHints map[string]time.Duration
+
+	// ContainerFsHighThresholdPercent and ContainerFsLowThresholdPercent are
+	// the high/low threshold pair for the container (writable-layer)
+	// filesystem, for runtimes that report it separately from the image
+	// filesystem via CRI's ImageFsInfoResponse. GarbageCollect runs image
+	// deletion when usage crosses either filesystem's high threshold, and
+	// only stops once both filesystems are back under their respective low
+	// thresholds. Left at zero, both default to HighThresholdPercent and
+	// LowThresholdPercent, so runtimes that don't split the two filesystems
+	// are unaffected.
+	ContainerFsHighThresholdPercent int
+	ContainerFsLowThresholdPercent  int
+}
+
+// GCPlanReason explains why an image was selected for removal in a GCPlan.
+type GCPlanReason string
+
+const (
+	// GCPlanReasonDiskPressure is used for images evicted to bring usage
+	// back down to LowThresholdPercent.
+	GCPlanReasonDiskPressure GCPlanReason = "disk_pressure"
+	// GCPlanReasonMaxAge is used for images evicted for sitting unused
+	// longer than MaxAge.
+	GCPlanReasonMaxAge GCPlanReason = "max_age"
+)
+
+// GCPlanEntry describes a single image that a garbage collection pass would
+// remove.
+type GCPlanEntry struct {
+	ImageID  string
+	Size     int64
+	LastUsed time.Time
+	Reason   GCPlanReason
+}
+
+// GCPlan is the ordered set of images a garbage collection pass would
+// remove, without it having actually removed anything.
+type GCPlan struct {
+	Entries []GCPlanEntry
+}
+
+// protectedImageEvictedEventReason is the event reason recorded when an
+// image is evicted despite still being within policy.ProtectionWindow,
+// because honoring the window would have left the filesystem over its low
+// threshold.
+const protectedImageEvictedEventReason = "ProtectedImageEvicted"
+
+// pinnedImagesPreventReclaimEventReason is the event reason recorded when
+// GarbageCollect cannot reach policy.LowThresholdPercent because too much
+// image filesystem space is held by pinned images.
+const pinnedImagesPreventReclaimEventReason = "PinnedImagesPreventReclaim"
+
+// namespaceQuotaImageEvictedEventReason is the event reason recorded when an
+// image is evicted ahead of its LRU position because reorderForNamespaceQuotas
+// found its owning pods' namespace over its configured quota share.
+const namespaceQuotaImageEvictedEventReason = "NamespaceQuotaImageEvicted"
+
+// NamespaceImageQuota caps the disk share of images whose most recent users
+// matched a namespace selector or fell below a priority threshold. It does
+// not reserve disk share for them.
+type NamespaceImageQuota struct {
+	// NamespaceSelector is a shell glob pattern (as accepted by path.Match)
+	// matched against the namespace of a pod that used the image.
+	NamespaceSelector string
+
+	// PriorityThreshold, when set, causes this quota to also apply to images
+	// whose most recent using pod has a priority strictly below the threshold.
+	// Pod priority is supplied by the function registered via
+	// SetPodPriorityLookup; until one is registered, priority-based matching
+	// is inert and only NamespaceSelector is evaluated.
+	PriorityThreshold *int32
+
+	// MaxPercent caps the share of tracked image bytes that images matching
+	// this quota are allowed to consume. Once exceeded, matching images
+	// become preferred eviction candidates regardless of how recently they
+	// were used.
+	MaxPercent int
+}
+
+type realImageGCManager struct {
+	// Container runtime
+	runtime container.Runtime
+
+	// Records of images and their use.
+	imageRecordsLock sync.Mutex
+	imageRecords     map[string]*imageRecord
+
+	// The image garbage collection policy in use.
+	policy ImageGCPolicy
+
+	// statsProvider provides stats used to determine filesystem usage.
+	statsProvider stats.Provider
+
+	// Recorder for Kubernetes events.
+	recorder record.EventRecorder
+
+	// Reference to this node.
+	nodeRef *v1.ObjectReference
+
+	// tracer for recording spans for image garbage collection operations.
+	tracer oteltrace.Tracer
+
+	// podPriority, when set, resolves the priority of a running pod so that
+	// NamespaceImageQuota.PriorityThreshold can be evaluated. It is nil by
+	// default, in which case priority-based quota matching is skipped.
+	podPriority func(namespace, name string) (priority int32, ok bool)
+
+	// evictionPolicy ranks unused images for removal, as selected by
+	// policy.EvictionStrategy.
+	evictionPolicy EvictionPolicy
+
+	// evictionClock is the GDSF aging baseline ("L"); see gdsfEvictionPolicy.
+	// Guarded by imageRecordsLock, since it's read from detectImages and
+	// written from removeImageForSpace, which can run concurrently with the
+	// periodic Start() loop's own detectImages call.
+	evictionClock float64
+
+	// clock is used to evaluate policy.SoftThresholdGracePeriod. Defaults to
+	// the real clock; tests may override it directly.
+	clock clock.Clock
+
+	// softThresholdLock guards softThresholdFirstObserved.
+	softThresholdLock sync.Mutex
+
+	// softThresholdFirstObserved is the first time usage was observed at or
+	// above policy.SoftThresholdPercent since it last dropped back below.
+	// Zero means usage is not currently over the soft threshold.
+	softThresholdFirstObserved time.Time
+
+	// gcLock serializes full GarbageCollect runs, so a hard-threshold event
+	// delivered through SetEvictionSignals can't run concurrently with a
+	// periodic GarbageCollect call.
+	gcLock sync.Mutex
+}
+
+// SetPodPriorityLookup registers a function used to resolve a running pod's
+// priority for NamespaceImageQuota.PriorityThreshold evaluation. It is
+// optional; callers that don't need priority-based quotas can leave it unset.
+func (im *realImageGCManager) SetPodPriorityLookup(lookup func(namespace, name string) (int32, bool)) {
+	im.podPriority = lookup
+}
+
+// SetEvictionSignals registers a channel the eviction manager can use to
+// push SignalImageFsAvailable/SignalNodeFsAvailable threshold crossings
+// into image GC. A crossing with no grace period (a hard threshold)
+// triggers an immediate GarbageCollect pass instead of waiting for the next
+// periodic GarbageCollect call. That pass reads the filesystem stats itself
+// and reclaims only if they cross a configured threshold, like any other
+// pass; the crossing alone does not count as usage over
+// policy.HighThresholdPercent. It is optional; callers that only want periodic GC
+// can leave it unset. The caller owns signals and should close it to stop
+// the watch goroutine.
+func (im *realImageGCManager) SetEvictionSignals(signals <-chan evictionapi.Threshold) {
+	go func() {
+		for threshold := range signals {
+			if err := checkHighThresholdAgainstHardEviction(im.policy.HighThresholdPercent, threshold); err != nil {
+				klog.InfoS("ImageGCPolicy may not reclaim space before the eviction manager forces pod eviction", "err", err)
+			}
+
+			if !isHardImageThreshold(threshold) {
+				continue
+			}
+
+			klog.InfoS("Received a hard eviction threshold crossing, running image garbage collection immediately", "signal", threshold.Signal)
+			if err := im.GarbageCollect(context.Background()); err != nil {
+				klog.InfoS("Eviction-triggered image garbage collection failed", "err", err)
+			}
+		}
+	}()
+}
+
+// isHardImageThreshold reports whether threshold concerns the image or node
+// filesystem and has no grace period, i.e. it demands immediate reclaim the
+// same way policy.HighThresholdPercent does.
+func isHardImageThreshold(threshold evictionapi.Threshold) bool {
+	if threshold.GracePeriod != 0 {
+		return false
+	}
+	switch threshold.Signal {
+	case evictionapi.SignalImageFsAvailable, evictionapi.SignalNodeFsAvailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// hardThresholdUsagePercent converts a hard eviction threshold's available
+// percentage into the equivalent used-percentage terms ImageGCPolicy
+// reasons in, returning false if threshold wasn't expressed as a
+// percentage.
+func hardThresholdUsagePercent(threshold evictionapi.Threshold) (int, bool) {
+	if threshold.Value.Percentage <= 0 {
+		return 0, false
+	}
+	return int((1 - threshold.Value.Percentage) * 100), true
+}
+
+// checkHighThresholdAgainstHardEviction reports an error if
+// highThresholdPercent would let image GC wait until usage has already
+// passed the point at which the matching hard eviction threshold forces pod
+// eviction, defeating image GC's role as the gentler, earlier alternative.
+func checkHighThresholdAgainstHardEviction(highThresholdPercent int, threshold evictionapi.Threshold) error {
+	if !isHardImageThreshold(threshold) {
+		return nil
+	}
+	hardUsagePercent, ok := hardThresholdUsagePercent(threshold)
+	if !ok {
+		return nil
+	}
+	if highThresholdPercent > hardUsagePercent {
+		return fmt.Errorf("ImageGCPolicy.HighThresholdPercent %d must not be looser than the hard eviction threshold for %s, which triggers at %d%% usage", highThresholdPercent, threshold.Signal, hardUsagePercent)
+	}
+	return nil
+}
+
+// startSpan starts a span on im.tracer, falling back to a noop tracer for
+// managers constructed without one (e.g. directly in tests).
+func (im *realImageGCManager) startSpan(ctx context.Context, name string) (context.Context, oteltrace.Span) {
+	tracer := im.tracer
+	if tracer == nil {
+		tracer = oteltrace.NewNoopTracerProvider().Tracer("")
+	}
+	return tracer.Start(ctx, name)
+}
+
+// now returns the current time from im.clock, falling back to the real
+// clock for managers constructed without one (e.g. directly in tests).
+func (im *realImageGCManager) now() time.Time {
+	if im.clock == nil {
+		return time.Now()
+	}
+	return im.clock.Now()
+}
+
+// overThreshold reports whether usagePercent currently warrants garbage
+// collection, and updates the policy.SoftThresholdGracePeriod timer as a
+// side effect. HighThresholdPercent triggers immediately; SoftThresholdPercent
+// only triggers once usage has remained at or above it for
+// SoftThresholdGracePeriod, so a transient spike from a single large pull
+// doesn't wake unnecessary eviction.
+func (im *realImageGCManager) overThreshold(usagePercent int) bool {
+	if usagePercent >= im.policy.HighThresholdPercent {
+		return true
+	}
+
+	if im.policy.SoftThresholdPercent == 0 {
+		return false
+	}
+
+	im.softThresholdLock.Lock()
+	defer im.softThresholdLock.Unlock()
+
+	if usagePercent < im.policy.SoftThresholdPercent {
+		im.softThresholdFirstObserved = time.Time{}
+		return false
+	}
+
+	now := im.now()
+	if im.softThresholdFirstObserved.IsZero() {
+		im.softThresholdFirstObserved = now
+	}
+	return now.Sub(im.softThresholdFirstObserved) >= im.policy.SoftThresholdGracePeriod
+}
+
+// overThresholdPeek reports whether usagePercent currently warrants garbage
+// collection, exactly like overThreshold, but without starting or advancing
+// the policy.SoftThresholdGracePeriod timer. It lets PlanGarbageCollect
+// inspect the soft-threshold state without its polling cadence changing when
+// GarbageCollect's own grace period actually elapses.
+func (im *realImageGCManager) overThresholdPeek(usagePercent int) bool {
+	if usagePercent >= im.policy.HighThresholdPercent {
+		return true
+	}
+
+	if im.policy.SoftThresholdPercent == 0 {
+		return false
+	}
+
+	im.softThresholdLock.Lock()
+	defer im.softThresholdLock.Unlock()
+
+	if usagePercent < im.policy.SoftThresholdPercent || im.softThresholdFirstObserved.IsZero() {
+		return false
+	}
+
+	return im.now().Sub(im.softThresholdFirstObserved) >= im.policy.SoftThresholdGracePeriod
+}
+
+// imageRecord is the list of data that we track about each image.
+type imageRecord struct {
+	// firstDetected is the time when the image was first detected.
+	firstDetected time.Time
+
+	// lastUsed is the last time the image was used.
+	lastUsed time.Time
+
+	// size is the size of the image in bytes.
+	size int64
+
+	// pinned indicates whether this image is exempt from garbage
+	// collection, either because the runtime reported it as pinned or
+	// because it matched policy.PinnedImageRefs or PinnedImagePatterns.
+	pinned bool
+
+	// owningPods tracks the namespace/name of pods currently running a
+	// container backed by this image, so quota buckets can be evaluated
+	// against the image's most recent users. Populated in detectImages.
+	owningPods sets.Set[string]
+
+	// useCount is incremented each time detectImages observes a running
+	// container referencing this image. Used by the "lfu" and "gdsf"
+	// eviction policies.
+	useCount int64
+
+	// agingClock is the GDSF aging baseline ("L") in effect when this
+	// record was first detected; see gdsfEvictionPolicy.
+	agingClock float64
+
+	// pulledAt is when the image was first observed on the node. Unlike
+	// firstDetected, which callers may seed with an arbitrary detectTime in
+	// tests, pulledAt always reflects wall-clock time and backs
+	// policy.ProtectionWindow.
+	pulledAt time.Time
+
+	// registryHost is the registry host parsed from the image's first
+	// reported repo tag or repo digest, used by the "cost-weighted"
+	// eviction policy to apply policy.RegistryLatencyHints. Empty if the
+	// runtime reported no references for the image.
+	registryHost string
+}
+
+// evictionInfo pairs an imageRecord with the id of the image it describes,
+// for use while ranking images for eviction.
+type evictionInfo struct {
+	id string
+	imageRecord
+
+	// overQuota is set by reorderForNamespaceQuotas when the image was moved
+	// ahead of LRU order because its owning pods belong to an over-quota
+	// namespace bucket.
+	overQuota bool
+}
+
+// NewImageGCManager instantiates a new ImageGCManager object.
+func NewImageGCManager(runtime container.Runtime, statsProvider stats.Provider, recorder record.EventRecorder, nodeRef *v1.ObjectReference, policy ImageGCPolicy, tracerProvider oteltrace.TracerProvider) (ImageGCManager, error) {
+	if policy.HighThresholdPercent < 0 || policy.HighThresholdPercent > 100 {
+		return nil, fmt.Errorf("invalid HighThresholdPercent %d, must be in range [0-100]", policy.HighThresholdPercent)
+	}
+	if policy.LowThresholdPercent < 0 || policy.LowThresholdPercent > 100 {
+		return nil, fmt.Errorf("invalid LowThresholdPercent %d, must be in range [0-100]", policy.LowThresholdPercent)
+	}
+	if policy.LowThresholdPercent > policy.HighThresholdPercent {
+		return nil, fmt.Errorf("LowThresholdPercent %d can not be higher than HighThresholdPercent %d", policy.LowThresholdPercent, policy.HighThresholdPercent)
+	}
+	if policy.SoftThresholdGracePeriod < 0 {
+		return nil, fmt.Errorf("invalid SoftThresholdGracePeriod %v, must not be negative", policy.SoftThresholdGracePeriod)
+	}
+	if policy.SoftThresholdPercent != 0 {
+		if policy.SoftThresholdPercent < 0 || policy.SoftThresholdPercent > 100 {
+			return nil, fmt.Errorf("invalid SoftThresholdPercent %d, must be in range [0-100]", policy.SoftThresholdPercent)
+		}
+		if policy.SoftThresholdPercent < policy.LowThresholdPercent {
+			return nil, fmt.Errorf("SoftThresholdPercent %d can not be lower than LowThresholdPercent %d", policy.SoftThresholdPercent, policy.LowThresholdPercent)
+		}
+		if policy.SoftThresholdPercent > policy.HighThresholdPercent {
+			return nil, fmt.Errorf("SoftThresholdPercent %d can not be higher than HighThresholdPercent %d", policy.SoftThresholdPercent, policy.HighThresholdPercent)
+		}
+	}
+	if policy.ContainerFsHighThresholdPercent == 0 {
+		policy.ContainerFsHighThresholdPercent = policy.HighThresholdPercent
+	}
+	if policy.ContainerFsLowThresholdPercent == 0 {
+		policy.ContainerFsLowThresholdPercent = policy.LowThresholdPercent
+	}
+	if policy.ContainerFsHighThresholdPercent < 0 || policy.ContainerFsHighThresholdPercent > 100 {
+		return nil, fmt.Errorf("invalid ContainerFsHighThresholdPercent %d, must be in range [0-100]", policy.ContainerFsHighThresholdPercent)
+	}
+	if policy.ContainerFsLowThresholdPercent < 0 || policy.ContainerFsLowThresholdPercent > 100 {
+		return nil, fmt.Errorf("invalid ContainerFsLowThresholdPercent %d, must be in range [0-100]", policy.ContainerFsLowThresholdPercent)
+	}
+	if policy.ContainerFsLowThresholdPercent > policy.ContainerFsHighThresholdPercent {
+		return nil, fmt.Errorf("ContainerFsLowThresholdPercent %d can not be higher than ContainerFsHighThresholdPercent %d", policy.ContainerFsLowThresholdPercent, policy.ContainerFsHighThresholdPercent)
+	}
+	evictionPolicy, err := evictionPolicyForStrategy(policy.EvictionStrategy, policy.RegistryLatencyHints)
+	if err != nil {
+		return nil, err
+	}
+	registerImageGCMetrics()
+
+	im := &realImageGCManager{
+		runtime:        runtime,
+		policy:         policy,
+		imageRecords:   make(map[string]*imageRecord),
+		statsProvider:  statsProvider,
+		recorder:       recorder,
+		nodeRef:        nodeRef,
+		tracer:         tracerProvider.Tracer("k8s.io/kubernetes/pkg/kubelet/images"),
+		evictionPolicy: evictionPolicy,
+		clock:          clock.RealClock{},
+	}
+
+	return im, nil
+}
+
+func (im *realImageGCManager) Start() {
+	go func() {
+		for {
+			select {
+			case <-time.After(5 * time.Minute):
+				if _, err := im.detectImages(context.Background(), time.Now()); err != nil {
+					klog.InfoS("Failed to monitor images", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// detectImages reconciles the tracked imageRecords with the images and containers
+// currently reported by the runtime, returning the set of image ids presently in use.
+func (im *realImageGCManager) detectImages(ctx context.Context, detectTime time.Time) (sets.Set[string], error) {
+	ctx, span := im.startSpan(ctx, "Images/detectImages")
+	defer span.End()
+
+	imagesInUse := sets.New[string]()
+
+	images, err := im.runtime.ListImages(ctx)
+	if err != nil {
+		return imagesInUse, err
+	}
+	pods, err := im.runtime.GetPods(ctx, true)
+	if err != nil {
+		return imagesInUse, err
+	}
+
+	owningPods := make(map[string]sets.Set[string])
+	for _, pod := range pods {
+		for _, c := range pod.Containers {
+			imagesInUse.Insert(c.ImageID)
+			podKey := pod.Namespace + "/" + pod.Name
+			if owningPods[c.ImageID] == nil {
+				owningPods[c.ImageID] = sets.New[string]()
+			}
+			owningPods[c.ImageID].Insert(podKey)
+		}
+	}
+
+	now := time.Now()
+	currentImages := sets.New[string]()
+	im.imageRecordsLock.Lock()
+	defer im.imageRecordsLock.Unlock()
+	for _, image := range images {
+		currentImages.Insert(image.ID)
+
+		if _, ok := im.imageRecords[image.ID]; !ok {
+			im.imageRecords[image.ID] = &imageRecord{
+				firstDetected: detectTime,
+				agingClock:    im.evictionClock,
+				pulledAt:      now,
+			}
+		}
+
+		if imagesInUse.Has(image.ID) {
+			im.imageRecords[image.ID].lastUsed = now
+			im.imageRecords[image.ID].owningPods = owningPods[image.ID]
+			im.imageRecords[image.ID].useCount++
+		}
+
+		im.imageRecords[image.ID].size = image.Size
+		im.imageRecords[image.ID].pinned = image.Pinned || im.imageMatchesPinPolicy(image)
+		im.imageRecords[image.ID].registryHost = primaryRegistryHost(image)
+	}
+
+	for image := range im.imageRecords {
+		if !currentImages.Has(image) {
+			delete(im.imageRecords, image)
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("images.total", len(images)),
+		attribute.Int("images.in_use", imagesInUse.Len()),
+	)
+	return imagesInUse, nil
+}
+
+// imagesInEvictionOrder returns the images that are currently unused, ordered
+// from the best eviction candidate to the worst, as ranked by im.evictionPolicy
+// (LRU if unset) with images from over-quota namespaces moved to the front.
+func (im *realImageGCManager) imagesInEvictionOrder(ctx context.Context, freeTime time.Time) ([]evictionInfo, error) {
+	ctx, span := im.startSpan(ctx, "Images/imagesInEvictionOrder")
+	defer span.End()
+
+	imagesInUse, err := im.detectImages(ctx, freeTime)
+	if err != nil {
+		return nil, err
+	}
+
+	im.imageRecordsLock.Lock()
+	images := make([]evictionInfo, 0, len(im.imageRecords))
+	for id, record := range im.imageRecords {
+		if imagesInUse.Has(id) {
+			continue
+		}
+		images = append(images, evictionInfo{
+			id:          id,
+			imageRecord: *record,
+		})
+	}
+	im.imageRecordsLock.Unlock()
+
+	evictionPolicy := im.evictionPolicy
+	if evictionPolicy == nil {
+		evictionPolicy = lruEvictionPolicy{}
+	}
+	images = evictionPolicy.Rank(images, freeTime)
+	im.reorderForNamespaceQuotas(images)
+	span.SetAttributes(attribute.Int("images.total", len(images)))
+	return images, nil
+}
+
+// freeOldImages removes images that have gone unused for longer than policy.MaxAge,
+// regardless of current disk usage, and returns the images that remain.
+func (im *realImageGCManager) freeOldImages(ctx context.Context, images []evictionInfo, freeTime time.Time) ([]evictionInfo, error) {
+	if im.policy.MaxAge == 0 {
+		return images, nil
+	}
+
+	ctx, span := im.startSpan(ctx, "Images/freeOldImages")
+	defer span.End()
+	span.SetAttributes(attribute.String("policy.reason", string(GCPlanReasonMaxAge)))
+
+	var deletionErrors []error
+	var evictedCount int
+	var bytesReclaimed int64
+	remainingImages := make([]evictionInfo, 0, len(images))
+	for _, image := range images {
+		if freeTime.Sub(image.lastUsed) <= im.policy.MaxAge {
+			remainingImages = append(remainingImages, image)
+			continue
+		}
+
+		if image.pinned {
+			klog.V(5).InfoS("Image is pinned, skip garbage collection", "imageID", image.id)
+			remainingImages = append(remainingImages, image)
+			continue
+		}
+
+		// Unlike freeSpace, freeOldImages has no disk-pressure target to weigh
+		// against, so the protection window is always honored here.
+		if im.policy.ProtectionWindow != 0 && freeTime.Sub(image.pulledAt) < im.policy.ProtectionWindow {
+			klog.V(5).InfoS("Image was pulled recently, skip max-age garbage collection", "imageID", image.id, "protectionWindow", im.policy.ProtectionWindow)
+			remainingImages = append(remainingImages, image)
+			continue
+		}
+
+		klog.InfoS("Removing image because it's unused for longer than the max age", "imageID", image.id, "maxAge", im.policy.MaxAge)
+		if err := im.runtime.RemoveImage(ctx, container.ImageSpec{Image: image.id}); err != nil {
+			deletionErrors = append(deletionErrors, err)
+			remainingImages = append(remainingImages, image)
+			continue
+		}
+
+		im.imageRecordsLock.Lock()
+		delete(im.imageRecords, image.id)
+		im.imageRecordsLock.Unlock()
+		evictedCount++
+		bytesReclaimed += image.size
+	}
+
+	span.SetAttributes(attribute.Int("images.evicted", evictedCount), attribute.Int64("bytes.reclaimed", bytesReclaimed))
+	imageGCEvictionsTotal.WithLabelValues(im.evictionStrategyLabel(), string(GCPlanReasonMaxAge)).Add(float64(evictedCount))
+	imageGCReclaimedBytesTotal.Add(float64(bytesReclaimed))
+
+	if len(deletionErrors) > 0 {
+		return remainingImages, fmt.Errorf("wanted to free images older than %v, encountered errors in image deletion: %v", im.policy.MaxAge, utilerrors.NewAggregate(deletionErrors))
+	}
+	return remainingImages, nil
+}
+
+// freeSpace removes images, ordered as given, until at least bytesToFree bytes have
+// been reclaimed or the list of candidates is exhausted. Images still within
+// policy.ProtectionWindow are only removed after all other candidates. It
+// returns the number of bytes actually freed.
+func (im *realImageGCManager) freeSpace(ctx context.Context, bytesToFree int64, freeTime time.Time, images []evictionInfo) (int64, error) {
+	ctx, span := im.startSpan(ctx, "Images/freeSpace")
+	defer span.End()
+	span.SetAttributes(attribute.String("policy.reason", string(GCPlanReasonDiskPressure)))
+
+	var deletionErrors []error
+	var protected []evictionInfo
+	var evictedCount int
+	spaceFreed := int64(0)
+	for _, image := range images {
+		if spaceFreed >= bytesToFree {
+			break
+		}
+
+		if image.pinned {
+			klog.V(5).InfoS("Image is pinned, skip garbage collection", "imageID", image.id)
+			continue
+		}
+
+		if im.policy.MinAge != 0 && freeTime.Sub(image.firstDetected) < im.policy.MinAge {
+			klog.V(5).InfoS("Image is not old enough, skip garbage collection", "imageID", image.id, "minAge", im.policy.MinAge)
+			continue
+		}
+
+		if im.policy.ProtectionWindow != 0 && freeTime.Sub(image.pulledAt) < im.policy.ProtectionWindow {
+			klog.V(5).InfoS("Image was pulled recently, skip garbage collection", "imageID", image.id, "protectionWindow", im.policy.ProtectionWindow)
+			protected = append(protected, image)
+			continue
+		}
+
+		freed, err := im.removeImageForSpace(ctx, image)
+		if err != nil {
+			deletionErrors = append(deletionErrors, err)
+			continue
+		}
+		spaceFreed += freed
+		evictedCount++
+		if image.overQuota {
+			im.recorder.Eventf(im.nodeRef, v1.EventTypeNormal, namespaceQuotaImageEvictedEventReason, "Removed image %s ahead of its LRU position because its namespace exceeded its configured image disk quota", image.id)
+		}
+	}
+
+	// Honoring the protection window left us short of the requested amount;
+	// fall back to evicting recently-pulled images too, since a pending pod
+	// needing disk space takes priority over protecting a fresh pull.
+	for _, image := range protected {
+		if spaceFreed >= bytesToFree {
+			break
+		}
+
+		klog.InfoS("Evicting recently-pulled image to reach the requested free space", "imageID", image.id, "protectionWindow", im.policy.ProtectionWindow)
+		freed, err := im.removeImageForSpace(ctx, image)
+		if err != nil {
+			deletionErrors = append(deletionErrors, err)
+			continue
+		}
+		spaceFreed += freed
+		evictedCount++
+		im.recorder.Eventf(im.nodeRef, v1.EventTypeWarning, protectedImageEvictedEventReason, "Removed image %s despite being within the %s protection window because disk space was still needed", image.id, im.policy.ProtectionWindow)
+	}
+
+	span.SetAttributes(attribute.Int("images.evicted", evictedCount), attribute.Int64("bytes.reclaimed", spaceFreed))
+	imageGCEvictionsTotal.WithLabelValues(im.evictionStrategyLabel(), string(GCPlanReasonDiskPressure)).Add(float64(evictedCount))
+	imageGCReclaimedBytesTotal.Add(float64(spaceFreed))
+
+	if len(deletionErrors) > 0 {
+		return spaceFreed, fmt.Errorf("wanted to free %d bytes, but freed %d bytes space with errors in image deletion: %v", bytesToFree, spaceFreed, utilerrors.NewAggregate(deletionErrors))
+	}
+	return spaceFreed, nil
+}
+
+// removeImageForSpace removes image's backing image from the runtime and its
+// tracked record, returning the number of bytes reclaimed.
+func (im *realImageGCManager) removeImageForSpace(ctx context.Context, image evictionInfo) (int64, error) {
+	klog.InfoS("Removing image to free up space", "imageID", image.id, "size", image.size)
+	if err := im.runtime.RemoveImage(ctx, container.ImageSpec{Image: image.id}); err != nil {
+		return 0, err
+	}
+
+	im.imageRecordsLock.Lock()
+	delete(im.imageRecords, image.id)
+	if _, ok := im.evictionPolicy.(gdsfEvictionPolicy); ok {
+		im.evictionClock = gdsfEvictionPolicy{}.score(image)
+	}
+	im.imageRecordsLock.Unlock()
+
+	return image.size, nil
+}
+
+// evictionStrategyLabel returns the effective EvictionStrategy name used as
+// the "strategy" label on imageGCEvictionsTotal, defaulting to "lru" to
+// match evictionPolicyForStrategy's treatment of the empty string.
+func (im *realImageGCManager) evictionStrategyLabel() string {
+	if im.policy.EvictionStrategy == "" {
+		return "lru"
+	}
+	return im.policy.EvictionStrategy
+}
+
+// fsUsage computes the usage percentage and raw capacity/available bytes
+// from an FsStats sample, clamping an over-reported available to capacity.
+// Returns all zero if fsStats reported no capacity.
+func fsUsage(fsStats *statsapi.FsStats) (usagePercent int, capacity, available int64) {
+	if fsStats.CapacityBytes != nil {
+		capacity = int64(*fsStats.CapacityBytes)
+	}
+	if fsStats.AvailableBytes != nil {
+		available = int64(*fsStats.AvailableBytes)
+	}
+	if available > capacity {
+		available = capacity
+	}
+	if capacity == 0 {
+		return 0, 0, 0
+	}
+	return 100 - int(available*100/capacity), capacity, available
+}
+
+// amountToFreeFor returns the number of bytes that must be reclaimed from a
+// filesystem with the given capacity/available to bring its usage back down
+// to lowThresholdPercent.
+func amountToFreeFor(capacity, available int64, lowThresholdPercent int) int64 {
+	return capacity*int64(100-lowThresholdPercent)/100 - available
+}
+
+// containerFsUsage returns the container (writable-layer) filesystem's usage
+// percentage and raw capacity/available bytes. It returns all zero, without
+// error, if neither ContainerFsHighThresholdPercent nor
+// ContainerFsLowThresholdPercent is configured (e.g. policy wasn't built
+// through NewImageGCManager, which defaults both to HighThresholdPercent and
+// LowThresholdPercent), since there's then nothing to consult the runtime
+// about.
+func (im *realImageGCManager) containerFsUsage(ctx context.Context) (usagePercent int, capacity, available int64, err error) {
+	if im.policy.ContainerFsHighThresholdPercent == 0 && im.policy.ContainerFsLowThresholdPercent == 0 {
+		return 0, 0, 0, nil
+	}
+	stats, err := im.statsProvider.ContainerFsStats(ctx)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	usagePercent, capacity, available = fsUsage(stats)
+	return usagePercent, capacity, available, nil
+}
+
+// containerFsOverHighThreshold reports whether the container filesystem
+// (when its stats were available) is over its configured high threshold.
+func containerFsOverHighThreshold(capacity int64, usagePercent, highThresholdPercent int) bool {
+	return capacity != 0 && usagePercent >= highThresholdPercent
+}
+
+// pinnedBytes returns the total size of currently tracked images that are
+// pinned, whether by the runtime or by policy, and therefore unavailable to
+// freeSpace as reclaimable candidates.
+func (im *realImageGCManager) pinnedBytes() int64 {
+	im.imageRecordsLock.Lock()
+	defer im.imageRecordsLock.Unlock()
+
+	var total int64
+	for _, record := range im.imageRecords {
+		if record.pinned {
+			total += record.size
+		}
+	}
+	return total
+}
+
+func (im *realImageGCManager) GetImageList() ([]container.Image, error) {
+	return im.runtime.ListImages(context.Background())
+}
+
+// DeleteUnusedImages deletes all unused images.
+func (im *realImageGCManager) DeleteUnusedImages(ctx context.Context) error {
+	klog.InfoS("Attempting to delete unused images")
+	freeTime := time.Now()
+	images, err := im.imagesInEvictionOrder(ctx, freeTime)
+	if err != nil {
+		return err
+	}
+	_, err = im.freeSpace(ctx, math.MaxInt64, freeTime, images)
+	return err
+}
+
+// GarbageCollect checks disk usage for the image filesystem and, if it crosses
+// policy.HighThresholdPercent, removes images until usage is back down to
+// policy.LowThresholdPercent.
+func (im *realImageGCManager) GarbageCollect(ctx context.Context) error {
+	// Serialize full runs so a hard-threshold event delivered through
+	// SetEvictionSignals can't race with a periodic call (or another signal)
+	// and execute two reclaim passes concurrently.
+	im.gcLock.Lock()
+	defer im.gcLock.Unlock()
+
+	ctx, span := im.startSpan(ctx, "Images/GarbageCollect")
+	defer span.End()
+
+	start := time.Now()
+	defer func() {
+		imageGCDurationSeconds.WithLabelValues("GarbageCollect").Observe(time.Since(start).Seconds())
+	}()
+
+	imageFsStats, err := im.statsProvider.ImageFsStats(ctx)
+	if err != nil {
+		return err
+	}
+	usagePercent, capacity, available := fsUsage(imageFsStats)
+	if capacity == 0 {
+		err := fmt.Errorf("invalid capacity %d on image filesystem", capacity)
+		im.recorder.Eventf(im.nodeRef, v1.EventTypeWarning, events.InvalidDiskCapacity, err.Error())
+		return err
+	}
+	span.SetAttributes(attribute.Int("usage.pct.before", usagePercent))
+
+	containerFsUsagePercent, containerFsCapacity, containerFsAvailable, err := im.containerFsUsage(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !im.overThreshold(usagePercent) && !containerFsOverHighThreshold(containerFsCapacity, containerFsUsagePercent, im.policy.ContainerFsHighThresholdPercent) {
+		klog.V(4).InfoS("Disk usage on the image and container filesystems is below their high and soft thresholds, nothing to do", "usagePercent", usagePercent, "highThreshold", im.policy.HighThresholdPercent, "softThreshold", im.policy.SoftThresholdPercent, "containerFsUsagePercent", containerFsUsagePercent, "containerFsHighThreshold", im.policy.ContainerFsHighThresholdPercent)
+		return nil
+	}
+
+	amountToFree := amountToFreeFor(capacity, available, im.policy.LowThresholdPercent)
+	if containerFsCapacity != 0 {
+		if shortfall := amountToFreeFor(containerFsCapacity, containerFsAvailable, im.policy.ContainerFsLowThresholdPercent); shortfall > amountToFree {
+			amountToFree = shortfall
+		}
+	}
+	klog.InfoS("Disk usage is over a high threshold, trying to free bytes down to the low threshold(s)", "usagePercent", usagePercent, "highThreshold", im.policy.HighThresholdPercent, "containerFsUsagePercent", containerFsUsagePercent, "containerFsHighThreshold", im.policy.ContainerFsHighThresholdPercent, "amountToFree", amountToFree)
+
+	freeTime := time.Now()
+
+	if im.policy.DryRun {
+		plan, err := im.planEvictions(ctx, freeTime, amountToFree)
+		if err != nil {
+			return err
+		}
+		klog.InfoS("DryRun is enabled, not removing any images; this is the plan that would have been executed", "entries", plan.Entries)
+		return nil
+	}
+
+	images, err := im.imagesInEvictionOrder(ctx, freeTime)
+	if err != nil {
+		return err
+	}
+	images, err = im.freeOldImages(ctx, images, freeTime)
+	if err != nil {
+		return err
+	}
+	freed, err := im.freeSpace(ctx, amountToFree, freeTime, images)
+	if err != nil {
+		return err
+	}
+
+	availableAfter := available + freed
+	if availableAfter > capacity {
+		availableAfter = capacity
+	}
+	span.SetAttributes(attribute.Int("usage.pct.after", 100-int(availableAfter*100/capacity)))
+
+	if freed < amountToFree {
+		if pinnedBytes := im.pinnedBytes(); pinnedBytes > 0 {
+			klog.InfoS("Unable to reach the low threshold because pinned images are holding disk space", "pinnedBytes", pinnedBytes, "shortfall", amountToFree-freed)
+			im.recorder.Eventf(im.nodeRef, v1.EventTypeWarning, pinnedImagesPreventReclaimEventReason, "Could not free enough disk space to reach the low threshold: %d bytes are held by images pinned by the runtime or by policy", pinnedBytes)
+		}
+		err := fmt.Errorf("failed to garbage collect required amount of images. Wanted to free %d bytes, but freed %d bytes", amountToFree, freed)
+		im.recorder.Eventf(im.nodeRef, v1.EventTypeWarning, events.FreeDiskSpaceFailed, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// PlanGarbageCollect returns the ordered list of images that GarbageCollect
+// would remove right now, without removing anything. It lets operators
+// validate policy changes safely, and lets other components (the eviction
+// manager, node problem detector) inspect upcoming reclaim actions.
+func (im *realImageGCManager) PlanGarbageCollect(ctx context.Context) (GCPlan, error) {
+	imageFsStats, err := im.statsProvider.ImageFsStats(ctx)
+	if err != nil {
+		return GCPlan{}, err
+	}
+	usagePercent, capacity, available := fsUsage(imageFsStats)
+	if capacity == 0 {
+		return GCPlan{}, fmt.Errorf("invalid capacity %d on image filesystem", capacity)
+	}
+
+	containerFsUsagePercent, containerFsCapacity, containerFsAvailable, err := im.containerFsUsage(ctx)
+	if err != nil {
+		return GCPlan{}, err
+	}
+
+	if !im.overThresholdPeek(usagePercent) && !containerFsOverHighThreshold(containerFsCapacity, containerFsUsagePercent, im.policy.ContainerFsHighThresholdPercent) {
+		return GCPlan{}, nil
+	}
+
+	amountToFree := amountToFreeFor(capacity, available, im.policy.LowThresholdPercent)
+	if containerFsCapacity != 0 {
+		if shortfall := amountToFreeFor(containerFsCapacity, containerFsAvailable, im.policy.ContainerFsLowThresholdPercent); shortfall > amountToFree {
+			amountToFree = shortfall
+		}
+	}
+	return im.planEvictions(ctx, time.Now(), amountToFree)
+}
+
+// planEvictions mirrors the selection logic of freeOldImages and freeSpace,
+// without removing any images, and records why each candidate was chosen.
+func (im *realImageGCManager) planEvictions(ctx context.Context, freeTime time.Time, amountToFree int64) (GCPlan, error) {
+	images, err := im.imagesInEvictionOrder(ctx, freeTime)
+	if err != nil {
+		return GCPlan{}, err
+	}
+
+	var plan GCPlan
+	remaining := make([]evictionInfo, 0, len(images))
+	for _, image := range images {
+		if im.policy.MaxAge != 0 && freeTime.Sub(image.lastUsed) > im.policy.MaxAge {
+			if image.pinned {
+				remaining = append(remaining, image)
+				continue
+			}
+			// Unlike freeSpace, freeOldImages has no disk-pressure target to
+			// weigh against, so the protection window is always honored;
+			// mirror that here so the plan doesn't list a removal that
+			// GarbageCollect will actually skip.
+			if im.policy.ProtectionWindow != 0 && freeTime.Sub(image.pulledAt) < im.policy.ProtectionWindow {
+				remaining = append(remaining, image)
+				continue
+			}
+			plan.Entries = append(plan.Entries, GCPlanEntry{
+				ImageID:  image.id,
+				Size:     image.size,
+				LastUsed: image.lastUsed,
+				Reason:   GCPlanReasonMaxAge,
+			})
+			continue
+		}
+		remaining = append(remaining, image)
+	}
+
+	for _, image := range remaining {
+		if amountToFree <= 0 {
+			break
+		}
+		if image.pinned {
+			continue
+		}
+		if im.policy.MinAge != 0 && freeTime.Sub(image.firstDetected) < im.policy.MinAge {
+			continue
+		}
+		if im.policy.ProtectionWindow != 0 && freeTime.Sub(image.pulledAt) < im.policy.ProtectionWindow {
+			continue
+		}
+
+		plan.Entries = append(plan.Entries, GCPlanEntry{
+			ImageID:  image.id,
+			Size:     image.size,
+			LastUsed: image.lastUsed,
+			Reason:   GCPlanReasonDiskPressure,
+		})
+		amountToFree -= image.size
+	}
+
+	return plan, nil
+}
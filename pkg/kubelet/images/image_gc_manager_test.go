@@ -708,6 +708,44 @@ func TestGarbageCollectImageTooOld(t *testing.T) {
 	assert.Len(fakeRuntime.ImageList, 1)
 }
 
+func TestGarbageCollectImageTooOldSkipsPinned(t *testing.T) {
+	ctx := context.Background()
+	policy := ImageGCPolicy{
+		HighThresholdPercent: 90,
+		LowThresholdPercent:  80,
+		MinAge:               0,
+		MaxAge:               time.Minute * 1,
+	}
+	fakeRuntime := &containertest.FakeRuntime{}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+	manager := &realImageGCManager{
+		runtime:       fakeRuntime,
+		policy:        policy,
+		imageRecords:  make(map[string]*imageRecord),
+		statsProvider: mockStatsProvider,
+		recorder:      &record.FakeRecorder{},
+	}
+
+	fakeRuntime.ImageList = []container.Image{
+		{ID: imageID(0), Size: 1024, Pinned: true},
+	}
+
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	images, err := manager.imagesInEvictionOrder(ctx, fakeClock.Now())
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+
+	// The image is old enough to be MaxAge-eligible, but being pinned must
+	// exempt it, the same way it exempts it from freeSpace.
+	fakeClock.Step(policy.MaxAge + 1)
+	images, err = manager.freeOldImages(ctx, images, fakeClock.Now())
+	require.NoError(t, err)
+	assert.Len(t, images, 1, "a pinned image must not be removed by the MaxAge pass")
+	assert.Len(t, fakeRuntime.ImageList, 1)
+}
+
 func TestGarbageCollectImageMaxAgeDisabled(t *testing.T) {
 	ctx := context.Background()
 	policy := ImageGCPolicy{
@@ -814,6 +852,97 @@ func TestValidateImageGCPolicy(t *testing.T) {
 			},
 			expectErr: "LowThresholdPercent 2 can not be higher than HighThresholdPercent 1",
 		},
+		{
+			name: "Test for valid EvictionStrategy",
+			imageGCPolicy: ImageGCPolicy{
+				EvictionStrategy: "gdsf",
+			},
+		},
+		{
+			name: "Test for invalid EvictionStrategy",
+			imageGCPolicy: ImageGCPolicy{
+				EvictionStrategy: "most-expensive-first",
+			},
+			expectErr: `invalid EvictionStrategy "most-expensive-first", must be one of "lru", "lfu", "size-weighted", "gdsf", "cost-weighted"`,
+		},
+		{
+			name: "Test for valid SoftThresholdPercent",
+			imageGCPolicy: ImageGCPolicy{
+				HighThresholdPercent: 90,
+				LowThresholdPercent:  70,
+				SoftThresholdPercent: 80,
+			},
+		},
+		{
+			name: "Test for SoftThresholdPercent < LowThresholdPercent",
+			imageGCPolicy: ImageGCPolicy{
+				HighThresholdPercent: 90,
+				LowThresholdPercent:  70,
+				SoftThresholdPercent: 60,
+			},
+			expectErr: "SoftThresholdPercent 60 can not be lower than LowThresholdPercent 70",
+		},
+		{
+			name: "Test for SoftThresholdPercent > HighThresholdPercent",
+			imageGCPolicy: ImageGCPolicy{
+				HighThresholdPercent: 90,
+				LowThresholdPercent:  70,
+				SoftThresholdPercent: 95,
+			},
+			expectErr: "SoftThresholdPercent 95 can not be higher than HighThresholdPercent 90",
+		},
+		{
+			name: "Test for negative SoftThresholdGracePeriod",
+			imageGCPolicy: ImageGCPolicy{
+				SoftThresholdGracePeriod: -time.Minute,
+			},
+			expectErr: "invalid SoftThresholdGracePeriod -1m0s, must not be negative",
+		},
+		{
+			name: "Test for valid ContainerFsHighThresholdPercent and ContainerFsLowThresholdPercent",
+			imageGCPolicy: ImageGCPolicy{
+				HighThresholdPercent:            90,
+				LowThresholdPercent:             80,
+				ContainerFsHighThresholdPercent: 95,
+				ContainerFsLowThresholdPercent:  85,
+			},
+		},
+		{
+			name: "Test for ContainerFsHighThresholdPercent < 0",
+			imageGCPolicy: ImageGCPolicy{
+				ContainerFsHighThresholdPercent: -1,
+			},
+			expectErr: "invalid ContainerFsHighThresholdPercent -1, must be in range [0-100]",
+		},
+		{
+			name: "Test for ContainerFsHighThresholdPercent > 100",
+			imageGCPolicy: ImageGCPolicy{
+				ContainerFsHighThresholdPercent: 101,
+			},
+			expectErr: "invalid ContainerFsHighThresholdPercent 101, must be in range [0-100]",
+		},
+		{
+			name: "Test for ContainerFsLowThresholdPercent < 0",
+			imageGCPolicy: ImageGCPolicy{
+				ContainerFsLowThresholdPercent: -1,
+			},
+			expectErr: "invalid ContainerFsLowThresholdPercent -1, must be in range [0-100]",
+		},
+		{
+			name: "Test for ContainerFsLowThresholdPercent > 100",
+			imageGCPolicy: ImageGCPolicy{
+				ContainerFsLowThresholdPercent: 101,
+			},
+			expectErr: "invalid ContainerFsLowThresholdPercent 101, must be in range [0-100]",
+		},
+		{
+			name: "Test for ContainerFsLowThresholdPercent > ContainerFsHighThresholdPercent",
+			imageGCPolicy: ImageGCPolicy{
+				ContainerFsHighThresholdPercent: 70,
+				ContainerFsLowThresholdPercent:  85,
+			},
+			expectErr: "ContainerFsLowThresholdPercent 85 can not be higher than ContainerFsHighThresholdPercent 70",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,58 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var (
+	imageGCReclaimedBytesTotal = metrics.NewCounter(&metrics.CounterOpts{
+		Subsystem:      "kubelet",
+		Name:           "image_gc_reclaimed_bytes_total",
+		Help:           "Cumulative number of bytes reclaimed by image garbage collection.",
+		StabilityLevel: metrics.ALPHA,
+	})
+
+	imageGCEvictionsTotal = metrics.NewCounterVec(&metrics.CounterOpts{
+		Subsystem:      "kubelet",
+		Name:           "image_gc_evictions_total",
+		Help:           "Cumulative number of images removed by image garbage collection, by eviction strategy and reason.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"strategy", "reason"})
+
+	imageGCDurationSeconds = metrics.NewHistogramVec(&metrics.HistogramOpts{
+		Subsystem:      "kubelet",
+		Name:           "image_gc_duration_seconds",
+		Help:           "Duration in seconds of image garbage collection operations.",
+		Buckets:        metrics.DefBuckets,
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"operation"})
+
+	registerImageGCMetricsOnce sync.Once
+)
+
+// registerImageGCMetrics registers the image garbage collection metrics with
+// the legacy Prometheus registry. It is safe to call more than once.
+func registerImageGCMetrics() {
+	registerImageGCMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(imageGCReclaimedBytesTotal, imageGCEvictionsTotal, imageGCDurationSeconds)
+	})
+}
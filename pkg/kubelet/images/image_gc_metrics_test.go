@@ -0,0 +1,220 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/testutil"
+	statsapi "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+	"k8s.io/kubernetes/pkg/kubelet/container"
+	containertest "k8s.io/kubernetes/pkg/kubelet/container/testing"
+	statstest "k8s.io/kubernetes/pkg/kubelet/server/stats/testing"
+)
+
+// newRecordingImageGCManager builds a realImageGCManager the same way
+// production code does, through NewImageGCManager, except its tracer records
+// every completed span in memory instead of discarding them, so tests can
+// assert on the attributes GarbageCollect attaches to each span.
+func newRecordingImageGCManager(t *testing.T, policy ImageGCPolicy, mockStatsProvider *statstest.MockProvider) (*realImageGCManager, *containertest.FakeRuntime, *tracetest.SpanRecorder) {
+	spanRecorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+
+	fakeRuntime := &containertest.FakeRuntime{}
+	manager, err := NewImageGCManager(fakeRuntime, mockStatsProvider, &record.FakeRecorder{}, nil, policy, tracerProvider)
+	require.NoError(t, err)
+
+	return manager.(*realImageGCManager), fakeRuntime, spanRecorder
+}
+
+// findSpan returns the first recorded span with the given name, failing the
+// test if none was recorded.
+func findSpan(t *testing.T, spanRecorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range spanRecorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %q was recorded; got: %v", name, spanNames(spanRecorder))
+	return nil
+}
+
+func spanNames(spanRecorder *tracetest.SpanRecorder) []string {
+	var names []string
+	for _, span := range spanRecorder.Ended() {
+		names = append(names, span.Name())
+	}
+	return names
+}
+
+// spanAttr returns the value of attribute key on span, failing the test if
+// span does not carry it.
+func spanAttr(t *testing.T, span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	t.Helper()
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	t.Fatalf("span %q has no attribute %q; got: %v", span.Name(), key, span.Attributes())
+	return attribute.Value{}
+}
+
+func TestGarbageCollectRecordsSpansAndMetricsOnDiskPressure(t *testing.T) {
+	ctx := context.Background()
+	policy := ImageGCPolicy{
+		HighThresholdPercent: 90,
+		LowThresholdPercent:  80,
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+	manager, fakeRuntime, spanRecorder := newRecordingImageGCManager(t, policy, mockStatsProvider)
+
+	reclaimedBefore := testutil.ToFloat64(imageGCReclaimedBytesTotal)
+	evictionsBefore := testutil.ToFloat64(imageGCEvictionsTotal.WithLabelValues("lru", string(GCPlanReasonDiskPressure)))
+	durationSamplesBefore, err := testutil.CollectAndCount(imageGCDurationSeconds)
+	require.NoError(t, err)
+
+	mockStatsProvider.EXPECT().ImageFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(50),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+	mockStatsProvider.EXPECT().ContainerFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(900),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+	fakeRuntime.ImageList = []container.Image{
+		makeImage(0, 450),
+	}
+
+	require.NoError(t, manager.GarbageCollect(ctx))
+
+	assert.Equal(t, reclaimedBefore+450, testutil.ToFloat64(imageGCReclaimedBytesTotal))
+	assert.Equal(t, evictionsBefore+1, testutil.ToFloat64(imageGCEvictionsTotal.WithLabelValues("lru", string(GCPlanReasonDiskPressure))))
+
+	durationSamplesAfter, err := testutil.CollectAndCount(imageGCDurationSeconds)
+	require.NoError(t, err)
+	assert.Greater(t, durationSamplesAfter, durationSamplesBefore, "GarbageCollect should have recorded a sample on image_gc_duration_seconds")
+
+	gcSpan := findSpan(t, spanRecorder, "Images/GarbageCollect")
+	assert.Equal(t, int64(95), spanAttr(t, gcSpan, "usage.pct.before").AsInt64())
+	assert.Equal(t, int64(50), spanAttr(t, gcSpan, "usage.pct.after").AsInt64())
+
+	freeSpaceSpan := findSpan(t, spanRecorder, "Images/freeSpace")
+	assert.Equal(t, string(GCPlanReasonDiskPressure), spanAttr(t, freeSpaceSpan, "policy.reason").AsString())
+	assert.Equal(t, int64(1), spanAttr(t, freeSpaceSpan, "images.evicted").AsInt64())
+	assert.Equal(t, int64(450), spanAttr(t, freeSpaceSpan, "bytes.reclaimed").AsInt64())
+}
+
+func TestGarbageCollectRecordsSpansAndMetricsOnMaxAge(t *testing.T) {
+	ctx := context.Background()
+	policy := ImageGCPolicy{
+		HighThresholdPercent: 90,
+		LowThresholdPercent:  80,
+		MaxAge:               time.Hour,
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+	manager, fakeRuntime, spanRecorder := newRecordingImageGCManager(t, policy, mockStatsProvider)
+
+	maxAgeEvictionsBefore := testutil.ToFloat64(imageGCEvictionsTotal.WithLabelValues("lru", string(GCPlanReasonMaxAge)))
+
+	// image-0 is never used by any pod, so it's old enough to be reclaimed by
+	// the MaxAge pass. image-1 is used once to record a recent lastUsed, then
+	// freed up again, so it survives the MaxAge pass but is still a valid
+	// candidate for the disk-pressure pass that follows it.
+	fakeRuntime.ImageList = []container.Image{
+		makeImage(0, 500),
+		makeImage(1, 600),
+	}
+	fakeRuntime.AllPodList = []*containertest.FakePod{
+		{Pod: &container.Pod{
+			Namespace:  "default",
+			Name:       "pod-1",
+			Containers: []*container.Container{makeContainer(1)},
+		}},
+	}
+	_, err := manager.detectImages(ctx, time.Now())
+	require.NoError(t, err)
+	fakeRuntime.AllPodList = nil
+
+	mockStatsProvider.EXPECT().ImageFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(50),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+	mockStatsProvider.EXPECT().ContainerFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(900),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+
+	require.NoError(t, manager.GarbageCollect(ctx))
+
+	assert.Equal(t, maxAgeEvictionsBefore+1, testutil.ToFloat64(imageGCEvictionsTotal.WithLabelValues("lru", string(GCPlanReasonMaxAge))))
+	assert.Len(t, fakeRuntime.ImageList, 0, "image-0 should be reclaimed by MaxAge and image-1 by the disk-pressure pass that follows it")
+
+	maxAgeSpan := findSpan(t, spanRecorder, "Images/freeOldImages")
+	assert.Equal(t, string(GCPlanReasonMaxAge), spanAttr(t, maxAgeSpan, "policy.reason").AsString())
+	assert.Equal(t, int64(1), spanAttr(t, maxAgeSpan, "images.evicted").AsInt64())
+	assert.Equal(t, int64(500), spanAttr(t, maxAgeSpan, "bytes.reclaimed").AsInt64())
+}
+
+func TestGarbageCollectRecordsSpanWithoutEvictingWhenBelowThreshold(t *testing.T) {
+	ctx := context.Background()
+	policy := ImageGCPolicy{
+		HighThresholdPercent: 90,
+		LowThresholdPercent:  80,
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+	manager, fakeRuntime, spanRecorder := newRecordingImageGCManager(t, policy, mockStatsProvider)
+
+	mockStatsProvider.EXPECT().ImageFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(900),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+	mockStatsProvider.EXPECT().ContainerFsStats(gomock.Any()).Return(&statsapi.FsStats{
+		AvailableBytes: uint64Ptr(900),
+		CapacityBytes:  uint64Ptr(1000),
+	}, nil)
+	fakeRuntime.ImageList = []container.Image{
+		makeImage(0, 450),
+	}
+
+	require.NoError(t, manager.GarbageCollect(ctx))
+
+	assert.Len(t, fakeRuntime.ImageList, 1, "nothing should have been evicted while below the high threshold")
+	for _, name := range spanNames(spanRecorder) {
+		assert.NotEqual(t, "Images/freeSpace", name, "freeSpace should not run when usage is below the high threshold")
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"path"
+
+	"k8s.io/kubernetes/pkg/kubelet/container"
+)
+
+// imageMatchesPinPolicy reports whether image should be treated as pinned
+// because of policy.PinnedImageRefs or PinnedImagePatterns.
+func (im *realImageGCManager) imageMatchesPinPolicy(image container.Image) bool {
+	refs := imageRefs(image)
+	for _, ref := range refs {
+		for _, pinned := range im.policy.PinnedImageRefs {
+			if ref == pinned {
+				return true
+			}
+		}
+		for _, pattern := range im.policy.PinnedImagePatterns {
+			if matched, _ := path.Match(pattern, ref); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imageRefs returns every reference the runtime associates with image: its
+// ID plus any repo tags or repo digests it reports.
+func imageRefs(image container.Image) []string {
+	refs := make([]string, 0, 1+len(image.RepoTags)+len(image.RepoDigests))
+	refs = append(refs, image.ID)
+	refs = append(refs, image.RepoTags...)
+	refs = append(refs, image.RepoDigests...)
+	return refs
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/kubernetes/pkg/kubelet/container"
+	statstest "k8s.io/kubernetes/pkg/kubelet/server/stats/testing"
+)
+
+func TestImageMatchesPinPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ImageGCPolicy
+		image  container.Image
+		want   bool
+	}{
+		{
+			name:   "exact ref match",
+			policy: ImageGCPolicy{PinnedImageRefs: []string{"registry.k8s.io/base@sha256:deadbeef"}},
+			image:  container.Image{ID: "image-0", RepoDigests: []string{"registry.k8s.io/base@sha256:deadbeef"}},
+			want:   true,
+		},
+		{
+			name:   "no ref match",
+			policy: ImageGCPolicy{PinnedImageRefs: []string{"registry.k8s.io/base@sha256:deadbeef"}},
+			image:  container.Image{ID: "image-0", RepoDigests: []string{"registry.k8s.io/other@sha256:cafe"}},
+			want:   false,
+		},
+		{
+			name:   "glob pattern match on repo tag",
+			policy: ImageGCPolicy{PinnedImagePatterns: []string{"registry.k8s.io/base*"}},
+			image:  container.Image{ID: "image-0", RepoTags: []string{"registry.k8s.io/base:v1"}},
+			want:   true,
+		},
+		{
+			name:   "glob pattern no match",
+			policy: ImageGCPolicy{PinnedImagePatterns: []string{"registry.k8s.io/base*"}},
+			image:  container.Image{ID: "image-0", RepoTags: []string{"docker.io/library/nginx:latest"}},
+			want:   false,
+		},
+		{
+			name:   "no policy configured",
+			policy: ImageGCPolicy{},
+			image:  container.Image{ID: "image-0", RepoTags: []string{"registry.k8s.io/base:v1"}},
+			want:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			manager, _ := newRealImageGCManager(test.policy, statstest.NewMockProvider(mockCtrl))
+			assert.Equal(t, test.want, manager.imageMatchesPinPolicy(test.image))
+		})
+	}
+}
+
+func TestDetectImagesHonorsPolicyPin(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	policy := ImageGCPolicy{PinnedImageRefs: []string{"registry.k8s.io/base:v1"}}
+	manager, fakeRuntime := newRealImageGCManager(policy, mockStatsProvider)
+	fakeRuntime.ImageList = []container.Image{
+		{ID: imageID(0), Size: 1024, RepoTags: []string{"registry.k8s.io/base:v1"}},
+		makeImage(1, 1024),
+	}
+
+	_, err := manager.detectImages(ctx, zero)
+	require.NoError(t, err)
+
+	record, ok := manager.getImageRecord(imageID(0))
+	require.True(t, ok)
+	assert.True(t, record.pinned, "image matching PinnedImageRefs should be marked pinned")
+
+	record, ok = manager.getImageRecord(imageID(1))
+	require.True(t, ok)
+	assert.False(t, record.pinned)
+}
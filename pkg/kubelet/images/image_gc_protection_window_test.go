@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/kubelet/container"
+	statstest "k8s.io/kubernetes/pkg/kubelet/server/stats/testing"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestFreeSpaceHonorsProtectionWindow(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	policy := ImageGCPolicy{ProtectionWindow: time.Minute}
+	manager, fakeRuntime := newRealImageGCManager(policy, mockStatsProvider)
+	fakeRuntime.ImageList = []container.Image{
+		makeImage(0, 1024),
+		makeImage(1, 1024),
+	}
+
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	_, err := manager.detectImages(ctx, fakeClock.Now())
+	require.NoError(t, err)
+
+	// Age image 0 past the protection window, leave image 1 freshly pulled.
+	manager.imageRecords[imageID(0)].pulledAt = fakeClock.Now().Add(-2 * time.Minute)
+
+	images, err := manager.imagesInEvictionOrder(ctx, fakeClock.Now())
+	require.NoError(t, err)
+	require.Len(t, images, 2)
+
+	assert := assert.New(t)
+	spaceFreed, err := manager.freeSpace(ctx, 1024, fakeClock.Now(), images)
+	require.NoError(t, err)
+	assert.EqualValues(1024, spaceFreed)
+	assert.Len(fakeRuntime.ImageList, 1)
+	assert.Equal(imageID(1), fakeRuntime.ImageList[0].ID, "the freshly pulled image should have been protected")
+}
+
+func TestPlanEvictionsHonorsProtectionWindowForMaxAge(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	policy := ImageGCPolicy{
+		MaxAge:           time.Minute,
+		ProtectionWindow: time.Hour,
+	}
+	manager, fakeRuntime := newRealImageGCManager(policy, mockStatsProvider)
+	fakeRuntime.ImageList = []container.Image{
+		makeImage(0, 1024),
+	}
+
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	_, err := manager.detectImages(ctx, fakeClock.Now())
+	require.NoError(t, err)
+
+	// The image is old enough to clear MaxAge, but was pulled recently
+	// enough that it's still within ProtectionWindow; freeOldImages would
+	// skip it, so the plan must agree.
+	plan, err := manager.planEvictions(ctx, fakeClock.Now().Add(2*time.Minute), 0)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Entries, "a recently-pulled image within ProtectionWindow must not be planned for MaxAge removal")
+}
+
+func TestFreeSpaceForcesProtectedEvictionWhenNeeded(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	policy := ImageGCPolicy{ProtectionWindow: time.Minute}
+	manager, fakeRuntime := newRealImageGCManager(policy, mockStatsProvider)
+	fakeRecorder := &record.FakeRecorder{Events: make(chan string, 10)}
+	manager.recorder = fakeRecorder
+	fakeRuntime.ImageList = []container.Image{
+		makeImage(0, 1024),
+	}
+
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	_, err := manager.detectImages(ctx, fakeClock.Now())
+	require.NoError(t, err)
+
+	images, err := manager.imagesInEvictionOrder(ctx, fakeClock.Now())
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+
+	assert := assert.New(t)
+	// The only candidate is still within the protection window, but we need
+	// the space, so it must be evicted anyway.
+	spaceFreed, err := manager.freeSpace(ctx, 1024, fakeClock.Now(), images)
+	require.NoError(t, err)
+	assert.EqualValues(1024, spaceFreed)
+	assert.Len(fakeRuntime.ImageList, 0)
+
+	select {
+	case event := <-fakeRecorder.Events:
+		assert.Contains(event, protectedImageEvictedEventReason)
+	default:
+		t.Fatal("expected a ProtectedImageEvicted event to be recorded")
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"path"
+	"strings"
+)
+
+// reorderForNamespaceQuotas moves images whose owning pods belong to an
+// over-quota bucket to the front of images, ahead of the ordering already
+// applied by the caller's EvictionPolicy. Within each group (over-quota,
+// normal) the caller's relative order is preserved, since the partition
+// below is a stable split.
+func (im *realImageGCManager) reorderForNamespaceQuotas(images []evictionInfo) {
+	if len(im.policy.NamespaceQuotas) == 0 {
+		return
+	}
+
+	var totalBytes int64
+	for _, image := range images {
+		totalBytes += image.size
+	}
+	if totalBytes == 0 {
+		return
+	}
+
+	bucketBytes := make([]int64, len(im.policy.NamespaceQuotas))
+	for _, image := range images {
+		for i, quota := range im.policy.NamespaceQuotas {
+			if im.imageMatchesQuota(image, quota) {
+				bucketBytes[i] += image.size
+			}
+		}
+	}
+
+	overQuota := make([]evictionInfo, 0, len(images))
+	normal := make([]evictionInfo, 0, len(images))
+	for _, image := range images {
+		if im.imageOverQuota(image, bucketBytes, totalBytes) {
+			image.overQuota = true
+			overQuota = append(overQuota, image)
+		} else {
+			normal = append(normal, image)
+		}
+	}
+
+	copy(images, append(overQuota, normal...))
+}
+
+// imageOverQuota reports whether image belongs to any namespace quota bucket
+// that currently exceeds its configured MaxPercent share of tracked bytes.
+func (im *realImageGCManager) imageOverQuota(image evictionInfo, bucketBytes []int64, totalBytes int64) bool {
+	for i, quota := range im.policy.NamespaceQuotas {
+		if quota.MaxPercent <= 0 {
+			continue
+		}
+		if !im.imageMatchesQuota(image, quota) {
+			continue
+		}
+		if bucketBytes[i]*100/totalBytes > int64(quota.MaxPercent) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageMatchesQuota reports whether image's owning pods match quota's
+// namespace selector or priority threshold.
+func (im *realImageGCManager) imageMatchesQuota(image evictionInfo, quota NamespaceImageQuota) bool {
+	for podKey := range image.owningPods {
+		namespace, name, ok := strings.Cut(podKey, "/")
+		if !ok {
+			continue
+		}
+		if quota.NamespaceSelector != "" {
+			if matched, _ := path.Match(quota.NamespaceSelector, namespace); matched {
+				return true
+			}
+		}
+		if quota.PriorityThreshold != nil && im.podPriority != nil {
+			if priority, ok := im.podPriority(namespace, name); ok && priority < *quota.PriorityThreshold {
+				return true
+			}
+		}
+	}
+	return false
+}
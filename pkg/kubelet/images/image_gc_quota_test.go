@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/kubernetes/pkg/kubelet/container"
+	containertest "k8s.io/kubernetes/pkg/kubelet/container/testing"
+	statstest "k8s.io/kubernetes/pkg/kubelet/server/stats/testing"
+)
+
+// TestFreeSpaceEvictsOverQuotaNamespaceFirst verifies that an image whose
+// most recent user came from an over-quota namespace is evicted before a
+// more recently used image that belongs to a namespace with no quota
+// pressure, even though plain LRU order would prefer keeping the former and
+// evicting the latter.
+func TestFreeSpaceEvictsOverQuotaNamespaceFirst(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStatsProvider := statstest.NewMockProvider(mockCtrl)
+
+	policy := ImageGCPolicy{
+		NamespaceQuotas: []NamespaceImageQuota{
+			{NamespaceSelector: "untrusted-*", MaxPercent: 10},
+		},
+	}
+	manager, fakeRuntime := newRealImageGCManager(policy, mockStatsProvider)
+	fakeRuntime.ImageList = []container.Image{
+		makeImage(0, 1024),
+		makeImage(1, 1024),
+	}
+
+	// First round: only image-1 (trusted-system) is in use, so it becomes
+	// the least-recently-used image of the two.
+	fakeRuntime.AllPodList = []*containertest.FakePod{
+		{Pod: &container.Pod{
+			Namespace: "trusted-system",
+			Name:      "pod-1",
+			Containers: []*container.Container{
+				makeContainer(1),
+			},
+		}},
+	}
+	_, err := manager.detectImages(ctx, time.Time{})
+	require.NoError(t, err)
+
+	// Second round: only image-0 (untrusted-tenant) is in use, making it the
+	// most-recently-used image. Plain LRU order would therefore prefer
+	// keeping image-0 and evicting image-1 first, but image-0 belongs to an
+	// over-quota namespace and must be evicted first anyway.
+	fakeRuntime.AllPodList = []*containertest.FakePod{
+		{Pod: &container.Pod{
+			Namespace: "untrusted-tenant",
+			Name:      "pod-0",
+			Containers: []*container.Container{
+				makeContainer(0),
+			},
+		}},
+	}
+	_, err = manager.detectImages(ctx, time.Time{})
+	require.NoError(t, err)
+
+	record0, ok := manager.getImageRecord(imageID(0))
+	require.True(t, ok)
+	record1, ok := manager.getImageRecord(imageID(1))
+	require.True(t, ok)
+	require.True(t, record0.lastUsed.After(record1.lastUsed),
+		"fixture sanity check: image-0 should be the more recently used image")
+
+	// Neither pod is running any more, so both images are eviction candidates.
+	fakeRuntime.AllPodList = nil
+	images, err := manager.imagesInEvictionOrder(ctx, time.Now())
+	require.NoError(t, err)
+	require.Len(t, images, 2)
+	assert.Equal(t, imageID(0), images[0].id, "the over-quota namespace's image should be evicted first, even though it is the more recently used image")
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestOverThresholdWaitsOutSoftThresholdGracePeriod(t *testing.T) {
+	policy := ImageGCPolicy{
+		HighThresholdPercent:     90,
+		LowThresholdPercent:      80,
+		SoftThresholdPercent:     70,
+		SoftThresholdGracePeriod: time.Minute,
+	}
+	manager, _ := newRealImageGCManager(policy, nil)
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	manager.clock = fakeClock
+
+	assert := assert.New(t)
+	assert.False(manager.overThreshold(75), "usage just crossed the soft threshold, grace period hasn't elapsed")
+
+	fakeClock.Step(30 * time.Second)
+	assert.False(manager.overThreshold(75), "grace period still hasn't elapsed")
+
+	fakeClock.Step(31 * time.Second)
+	assert.True(manager.overThreshold(75), "usage has remained over the soft threshold for the full grace period")
+}
+
+func TestOverThresholdResetsTimerWhenUsageDropsBelowSoft(t *testing.T) {
+	policy := ImageGCPolicy{
+		HighThresholdPercent:     90,
+		LowThresholdPercent:      80,
+		SoftThresholdPercent:     70,
+		SoftThresholdGracePeriod: time.Minute,
+	}
+	manager, _ := newRealImageGCManager(policy, nil)
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	manager.clock = fakeClock
+
+	assert := assert.New(t)
+	assert.False(manager.overThreshold(75))
+
+	fakeClock.Step(45 * time.Second)
+	assert.False(manager.overThreshold(65), "usage dropped back below the soft threshold")
+
+	// A fresh excursion above the soft threshold must wait out the full
+	// grace period again; the earlier 45s shouldn't carry over.
+	fakeClock.Step(45 * time.Second)
+	assert.False(manager.overThreshold(75), "timer should have been reset when usage dropped below soft")
+
+	fakeClock.Step(61 * time.Second)
+	assert.True(manager.overThreshold(75))
+}
+
+func TestOverThresholdHighThresholdTriggersImmediately(t *testing.T) {
+	policy := ImageGCPolicy{
+		HighThresholdPercent:     90,
+		LowThresholdPercent:      80,
+		SoftThresholdPercent:     70,
+		SoftThresholdGracePeriod: time.Hour,
+	}
+	manager, _ := newRealImageGCManager(policy, nil)
+	manager.clock = testingclock.NewFakeClock(time.Now())
+
+	assert.True(t, manager.overThreshold(95), "usage over the hard threshold must trigger immediately, regardless of the soft threshold grace period")
+}
+
+func TestOverThresholdPeekDoesNotStartGracePeriodTimer(t *testing.T) {
+	policy := ImageGCPolicy{
+		HighThresholdPercent:     90,
+		LowThresholdPercent:      80,
+		SoftThresholdPercent:     70,
+		SoftThresholdGracePeriod: time.Minute,
+	}
+	manager, _ := newRealImageGCManager(policy, nil)
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	manager.clock = fakeClock
+
+	assert := assert.New(t)
+	assert.False(manager.overThresholdPeek(75), "peeking while under grace period must not report over threshold")
+
+	// If overThresholdPeek had started the grace-period timer the way
+	// overThreshold does, the real overThreshold call below would already
+	// see it as elapsed instead of just starting to wait it out.
+	fakeClock.Step(2 * time.Minute)
+	assert.False(manager.overThreshold(75), "overThreshold's own grace period must start fresh, unaffected by the earlier peek")
+
+	fakeClock.Step(61 * time.Second)
+	assert.True(manager.overThreshold(75), "usage has now remained over the soft threshold for the full grace period")
+}
+
+func TestOverThresholdSoftThresholdDisabledByDefault(t *testing.T) {
+	policy := ImageGCPolicy{
+		HighThresholdPercent: 90,
+		LowThresholdPercent:  80,
+	}
+	manager, _ := newRealImageGCManager(policy, nil)
+	manager.clock = testingclock.NewFakeClock(time.Now())
+
+	assert.False(t, manager.overThreshold(85), "SoftThresholdPercent is zero, so only the hard threshold should apply")
+}